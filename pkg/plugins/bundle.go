@@ -0,0 +1,185 @@
+package plugins
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/stripe/stripe-cli/pkg/config"
+)
+
+// bundleManifestEntry is the name plugins.toml is stored under inside a
+// plugin bundle tarball.
+const bundleManifestEntry = "plugins.toml"
+
+// bundleDir returns where an imported plugin bundle is unpacked to. Once a
+// bundle has been imported, GetPluginList reads its manifest from here and
+// RefreshPluginManifest becomes a no-op, so manifest refreshes need no
+// outbound network access. Binaries unpacked here are not looked up by
+// pluginBinaryPath, and InstallPlugin still downloads over HTTP.
+func bundleDir(config config.IConfig) string {
+	configPath := config.GetConfigFolder(os.Getenv("XDG_CONFIG_HOME"))
+	return filepath.Join(configPath, "plugin-bundle")
+}
+
+// hasImportedBundle reports whether `stripe plugin bundle import` has
+// already populated bundleDir.
+func hasImportedBundle(config config.IConfig, fs afero.Fs) bool {
+	exists, err := afero.Exists(fs, filepath.Join(bundleDir(config), bundleManifestEntry))
+	return err == nil && exists
+}
+
+// ExportBundle packages the current plugin manifest, plus the locally
+// installed binary of every plugin it lists for the current platform, into
+// a single gzipped tarball written to w. A listed binary that is not
+// installed here is left out of the bundle; nothing is downloaded. The
+// result can be carried into an air-gapped environment and loaded there
+// with ImportBundle.
+func ExportBundle(ctx context.Context, config config.IConfig, fs afero.Fs, w io.Writer) error {
+	pluginList, err := GetPluginList(ctx, config, fs)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	manifestPath := filepath.Join(config.GetConfigFolder(os.Getenv("XDG_CONFIG_HOME")), "plugins.toml")
+
+	if err := addFileToBundle(fs, tw, manifestPath, bundleManifestEntry); err != nil {
+		return err
+	}
+
+	for _, plugin := range pluginList.Plugins {
+		release, err := releaseForPlatform(plugin)
+		if err != nil {
+			// A plugin with no release for this platform can't be installed
+			// here anyway, so it's not worth failing the whole export over.
+			continue
+		}
+
+		binaryPath, err := pluginBinaryPath(config, plugin)
+		if err != nil {
+			return fmt.Errorf("could not add plugin %s (%s) to bundle: %w", plugin.Shortname, release.Version, err)
+		}
+
+		entryName := filepath.Join(plugin.Shortname, filepath.Base(binaryPath))
+
+		if err := addFileToBundle(fs, tw, binaryPath, entryName); err != nil {
+			return fmt.Errorf("could not add plugin %s (%s) to bundle: %w", plugin.Shortname, release.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// addFileToBundle copies the file at srcPath into tw under entryName,
+// skipping it silently if it doesn't exist (e.g. a listed plugin that was
+// never installed locally).
+func addFileToBundle(fs afero.Fs, tw *tar.Writer, srcPath, entryName string) error {
+	f, err := fs.Open(srcPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = entryName
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// sanitizedBundleEntryPath joins entryName onto dir, rejecting it if it's
+// absolute or climbs out of dir via "..". A bundle tarball is meant to be
+// carried across machines into air-gapped environments, i.e. it's
+// untrusted transport, so an entry like
+// "../../../../home/user/.ssh/authorized_keys" must not be allowed to
+// write outside dir (a tar-slip / path traversal).
+func sanitizedBundleEntryPath(dir, entryName string) (string, error) {
+	cleaned := filepath.Clean(entryName)
+
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("plugin bundle entry %q escapes the target directory", entryName)
+	}
+
+	return filepath.Join(dir, cleaned), nil
+}
+
+// ImportBundle unpacks a tarball produced by ExportBundle into bundleDir.
+// Afterwards, GetPluginList reads the plugin manifest out of the bundle and
+// RefreshPluginManifest is a no-op. The bundled binaries are unpacked under
+// bundleDir, where pluginBinaryPath does not look for them.
+func ImportBundle(config config.IConfig, fs afero.Fs, r io.Reader) error {
+	dir := bundleDir(config)
+
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		dest, err := sanitizedBundleEntryPath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := fs.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+
+		out, err := fs.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+
+		out.Close()
+	}
+
+	return nil
+}
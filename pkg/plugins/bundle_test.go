@@ -0,0 +1,41 @@
+package plugins
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizedBundleEntryPath(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator), "config", "plugin-bundle")
+
+	valid := []string{
+		"plugins.toml",
+		filepath.Join("stripe-foo", "stripe-foo"),
+	}
+
+	for _, entry := range valid {
+		dest, err := sanitizedBundleEntryPath(dir, entry)
+		if err != nil {
+			t.Errorf("sanitizedBundleEntryPath(%q, %q): unexpected error: %v", dir, entry, err)
+			continue
+		}
+
+		if !strings.HasPrefix(dest, dir+string(filepath.Separator)) {
+			t.Errorf("sanitizedBundleEntryPath(%q, %q) = %q, want a path under %q", dir, entry, dest, dir)
+		}
+	}
+
+	malicious := []string{
+		"../../../../home/user/.ssh/authorized_keys",
+		"..",
+		filepath.Join("..", "..", "etc", "passwd"),
+		"/etc/passwd",
+	}
+
+	for _, entry := range malicious {
+		if _, err := sanitizedBundleEntryPath(dir, entry); err == nil {
+			t.Errorf("sanitizedBundleEntryPath(%q, %q): expected an error, got none", dir, entry)
+		}
+	}
+}
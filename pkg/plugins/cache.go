@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+
+	"github.com/stripe/stripe-cli/pkg/config"
+)
+
+// pluginCacheDir returns where conditional-request metadata (and the last
+// successfully fetched manifest bodies) are cached, so a manifest refresh
+// can cheaply come back empty via a 304 instead of re-downloading an
+// unchanged plugins.toml every time. The signature is still fetched and
+// checked against the cached body on every refresh.
+func pluginCacheDir(config config.IConfig) string {
+	configPath := config.GetConfigFolder(os.Getenv("XDG_CONFIG_HOME"))
+	return filepath.Join(configPath, "plugin-cache")
+}
+
+// cacheKeyForURL derives a filesystem-safe cache key from a manifest URL.
+func cacheKeyForURL(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// manifestCacheMeta is the conditional-request state kept for a single
+// manifest URL.
+type manifestCacheMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func manifestCacheMetaPath(cacheDir, url string) string {
+	return filepath.Join(cacheDir, cacheKeyForURL(url)+".json")
+}
+
+func manifestCacheBodyPath(cacheDir, url string) string {
+	return filepath.Join(cacheDir, cacheKeyForURL(url)+".toml")
+}
+
+// loadManifestCacheMeta returns the cached ETag/Last-Modified for url, if
+// any. A missing or unreadable cache entry just means we have nothing to
+// send conditional headers with yet.
+func loadManifestCacheMeta(fs afero.Fs, cacheDir, url string) (manifestCacheMeta, bool) {
+	var meta manifestCacheMeta
+
+	raw, err := afero.ReadFile(fs, manifestCacheMetaPath(cacheDir, url))
+	if err != nil {
+		return meta, false
+	}
+
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return meta, false
+	}
+
+	return meta, true
+}
+
+// saveManifestCacheMeta persists the ETag/Last-Modified returned for url
+// alongside the response body that produced them.
+func saveManifestCacheMeta(fs afero.Fs, cacheDir, url string, meta manifestCacheMeta, body []byte) error {
+	if err := fs.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	if err := afero.WriteFile(fs, manifestCacheMetaPath(cacheDir, url), raw, 0644); err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, manifestCacheBodyPath(cacheDir, url), body, 0644)
+}
+
+// loadManifestCacheBody returns the body cached for url, used when a
+// conditional request comes back 304 Not Modified.
+func loadManifestCacheBody(fs afero.Fs, cacheDir, url string) ([]byte, error) {
+	return afero.ReadFile(fs, manifestCacheBodyPath(cacheDir, url))
+}
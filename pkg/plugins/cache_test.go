@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestFetchWithRetryRecoversFromServerError(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	if err := FetchRemoteResourceTo(server.URL, ioutil.Discard); err != nil {
+		t.Fatalf("FetchRemoteResourceTo: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Errorf("server received %d requests, want 2 (one failure, one retry that succeeds)", got)
+	}
+}
+
+func TestFetchWithRetryGivesUpOnNonRetryableStatus(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if err := FetchRemoteResourceTo(server.URL, ioutil.Discard); err == nil {
+		t.Fatal("expected a 404 to be a non-retryable error, got nil")
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("server received %d requests, want exactly 1 (a 404 shouldn't be retried)", got)
+	}
+}
+
+func TestFetchManifestCachedMissThenHit(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.Header.Get("If-None-Match") == `"etag-1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"etag-1"`)
+		w.Write([]byte("plugins = []"))
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	ctx := context.Background()
+
+	body, notModified, err := fetchManifestCached(ctx, fs, "/cache", server.URL)
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	if notModified {
+		t.Error("expected the first fetch to be a cache miss")
+	}
+	if string(body) != "plugins = []" {
+		t.Errorf("first fetch body = %q, want %q", body, "plugins = []")
+	}
+
+	body, notModified, err = fetchManifestCached(ctx, fs, "/cache", server.URL)
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if !notModified {
+		t.Error("expected the second fetch to be served from cache via a 304")
+	}
+	if string(body) != "plugins = []" {
+		t.Errorf("second fetch body = %q, want the cached body %q", body, "plugins = []")
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("server received %d requests, want 2 (one full fetch, one conditional)", got)
+	}
+}
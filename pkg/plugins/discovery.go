@@ -0,0 +1,145 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/afero"
+
+	"github.com/stripe/stripe-cli/pkg/config"
+)
+
+// localPluginPrefix is the filename prefix a local/dev plugin binary must
+// have to be discovered, mirroring Docker CLI's docker-<name> convention.
+const localPluginPrefix = "stripe-"
+
+// metadataSubcommand is invoked on a discovered binary to ask it to
+// describe itself.
+const metadataSubcommand = "stripe-cli-plugin-metadata"
+
+// pluginMetadataTimeout bounds how long we'll wait for a candidate binary
+// to print its metadata before giving up on it.
+const pluginMetadataTimeout = 5 * time.Second
+
+// localPluginMetadata is the JSON manifest a discovered plugin binary
+// prints on stdout when invoked with metadataSubcommand.
+type localPluginMetadata struct {
+	Name          string `json:"name"`
+	Shorthelp     string `json:"short_description"`
+	Version       string `json:"version"`
+	MinCLIVersion string `json:"min_cli_version"`
+}
+
+// discoveryDirs returns the directories scanned for local/dev plugin
+// binaries, in priority order: $STRIPE_PLUGIN_PATH entries first (most
+// specific to the invocation), then the user's plugins.d, then the
+// system-wide directory.
+func discoveryDirs(config config.IConfig) []string {
+	var dirs []string
+
+	if envPath := os.Getenv("STRIPE_PLUGIN_PATH"); envPath != "" {
+		dirs = append(dirs, filepath.SplitList(envPath)...)
+	}
+
+	configPath := config.GetConfigFolder(os.Getenv("XDG_CONFIG_HOME"))
+	dirs = append(dirs, filepath.Join(configPath, "plugins.d"))
+
+	dirs = append(dirs, "/usr/local/lib/stripe/plugins")
+
+	return dirs
+}
+
+// discoverLocalPlugins scans discoveryDirs for executables named
+// stripe-<name> and synthesizes a Plugin entry for each by invoking it
+// with metadataSubcommand. Plugins discovered this way don't need to
+// appear in any remote plugins.toml, so plugin authors can iterate
+// locally and ops teams can ship internal plugins without publishing
+// them to Stripe.
+func discoverLocalPlugins(config config.IConfig, fs afero.Fs) ([]Plugin, error) {
+	return discoverPluginsInDirs(fs, discoveryDirs(config))
+}
+
+// discoverPluginsInDirs is the pure scanning logic behind
+// discoverLocalPlugins, split out so it can be tested without a
+// config.IConfig.
+func discoverPluginsInDirs(fs afero.Fs, dirs []string) ([]Plugin, error) {
+	var discovered []Plugin
+	seen := make(map[string]bool)
+
+	for _, dir := range dirs {
+		entries, err := afero.ReadDir(fs, dir)
+		if err != nil {
+			// Discovery directories are optional; a missing one just means
+			// there's nothing to find there.
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			name := strings.TrimSuffix(entry.Name(), GetBinaryExtension())
+			if !strings.HasPrefix(name, localPluginPrefix) {
+				continue
+			}
+
+			shortname := strings.TrimPrefix(name, localPluginPrefix)
+			if seen[shortname] {
+				continue
+			}
+
+			binaryPath := filepath.Join(dir, entry.Name())
+
+			metadata, err := fetchLocalPluginMetadata(binaryPath)
+			if err != nil {
+				log.Debugf("skipping local plugin candidate %s: %v", binaryPath, err)
+				continue
+			}
+
+			seen[shortname] = true
+			discovered = append(discovered, Plugin{
+				Shortname:  shortname,
+				Binary:     name,
+				BinaryPath: binaryPath,
+				Shorthelp:  metadata.Shorthelp,
+			})
+		}
+	}
+
+	return discovered, nil
+}
+
+// fetchLocalPluginMetadata invokes a candidate binary with
+// metadataSubcommand and parses the JSON manifest it prints on stdout.
+func fetchLocalPluginMetadata(binaryPath string) (localPluginMetadata, error) {
+	var metadata localPluginMetadata
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginMetadataTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, binaryPath, metadataSubcommand)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return metadata, fmt.Errorf("could not run %s %s: %w", binaryPath, metadataSubcommand, err)
+	}
+
+	if err := json.Unmarshal(out, &metadata); err != nil {
+		return metadata, fmt.Errorf("could not parse metadata from %s: %w", binaryPath, err)
+	}
+
+	if metadata.Name == "" {
+		return metadata, fmt.Errorf("%s returned metadata with no name", binaryPath)
+	}
+
+	return metadata, nil
+}
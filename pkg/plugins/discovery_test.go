@@ -0,0 +1,66 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestDiscoverPluginsInDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins-discovery")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := "#!/bin/sh\necho '{\"name\":\"foo\",\"short_description\":\"does foo things\"}'\n"
+	binaryPath := filepath.Join(dir, "stripe-foo")
+
+	if err := ioutil.WriteFile(binaryPath, []byte(script), 0755); err != nil {
+		t.Fatalf("writing fixture plugin: %v", err)
+	}
+
+	discovered, err := discoverPluginsInDirs(afero.NewOsFs(), []string{dir})
+	if err != nil {
+		t.Fatalf("discoverPluginsInDirs: %v", err)
+	}
+
+	if len(discovered) != 1 {
+		t.Fatalf("got %d discovered plugins, want 1", len(discovered))
+	}
+
+	plugin := discovered[0]
+
+	if plugin.Shortname != "foo" {
+		t.Errorf("Shortname = %q, want %q", plugin.Shortname, "foo")
+	}
+
+	// Binary must stay a bare executable name, as it is for
+	// manifest-sourced plugins; the full location belongs in BinaryPath,
+	// which pluginBinaryPath returns as is for a discovered plugin.
+	if plugin.Binary != "stripe-foo" {
+		t.Errorf("Binary = %q, want a bare filename %q", plugin.Binary, "stripe-foo")
+	}
+
+	if plugin.BinaryPath != binaryPath {
+		t.Errorf("BinaryPath = %q, want %q", plugin.BinaryPath, binaryPath)
+	}
+
+	if plugin.Shorthelp != "does foo things" {
+		t.Errorf("Shorthelp = %q, want %q", plugin.Shorthelp, "does foo things")
+	}
+}
+
+func TestDiscoverPluginsInDirsMissingDir(t *testing.T) {
+	discovered, err := discoverPluginsInDirs(afero.NewOsFs(), []string{"/no/such/directory"})
+	if err != nil {
+		t.Fatalf("expected a missing discovery directory to be ignored, got error: %v", err)
+	}
+
+	if len(discovered) != 0 {
+		t.Errorf("got %d discovered plugins from a missing directory, want 0", len(discovered))
+	}
+}
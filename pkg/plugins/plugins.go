@@ -0,0 +1,37 @@
+package plugins
+
+// PluginsPath overrides the directory plugins are installed to. This is
+// primarily used by tests; in normal operation it is left empty and
+// getPluginsDir falls back to the user's config folder.
+var PluginsPath string
+
+// Release describes a single platform-specific build of a plugin.
+type Release struct {
+	Arch    string `toml:"arch"`
+	OS      string `toml:"os"`
+	Version string `toml:"version"`
+	Sum     string `toml:"sum"`
+}
+
+// Plugin contains the plugin name and shorthand command, as well as a list
+// of all of its releases.
+type Plugin struct {
+	Shortname        string    `toml:"shortname"`
+	Binary           string    `toml:"binary"`
+	MagicCookieValue string    `toml:"magic_cookie_value"`
+	Shorthelp        string    `toml:"shorthelp"`
+	Releases         []Release `toml:"releases"`
+
+	// BinaryPath is the absolute path to a locally discovered plugin's
+	// executable. It's only set for plugins found by discoverLocalPlugins;
+	// manifest-sourced plugins have no fixed on-disk location until
+	// they're installed, so they resolve their binary path from
+	// getPluginsDir + Shortname + Binary instead.
+	BinaryPath string `toml:"-"`
+}
+
+// PluginList contains the list of plugins the CLI is allowed to install
+// and run.
+type PluginList struct {
+	Plugins []Plugin `toml:"plugins"`
+}
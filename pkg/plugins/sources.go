@@ -0,0 +1,248 @@
+package plugins
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/afero"
+
+	"github.com/stripe/stripe-cli/pkg/config"
+)
+
+// defaultSourceName identifies plugins that came from Stripe's own
+// plugins.toml, as opposed to a third-party PluginSource.
+const defaultSourceName = "stripe"
+
+// defaultSourcePriority is the effective priority of the default Stripe
+// source in mergePluginSources. It's deliberately negative: a
+// `[[plugin_source]]` block that doesn't set `priority` gets Go's zero
+// value, 0, and 0 must outrank defaultSourcePriority so that an
+// otherwise-unconfigured third-party source silently wins a shortname
+// collision against the built-in manifest instead of tying with it (a tie
+// the user never asked for, since they never set a priority at all).
+const defaultSourcePriority = -1
+
+// reservedSourceNames are source names a `[[plugin_source]]` block may not
+// claim because the CLI itself uses them as identifiers: allowing a
+// third-party source named "stripe" would let it collide with the default
+// manifest's cache file (sourceManifestCachePath) and its identity in
+// mergePluginSources, impersonating the built-in source.
+var reservedSourceNames = map[string]bool{
+	defaultSourceName: true,
+}
+
+// PluginSource describes a third-party plugin repository a user has opted
+// into, registered as a `[[plugin_source]]` block in config.toml.
+type PluginSource struct {
+	Name      string `toml:"name"`
+	URL       string `toml:"url"`
+	PublicKey string `toml:"public_key"`
+	Priority  int    `toml:"priority"`
+}
+
+// pluginSourcesConfig is the shape of the subset of config.toml that
+// registers third-party plugin sources and restricts which ones the CLI
+// will honor.
+type pluginSourcesConfig struct {
+	PluginSource   []PluginSource `toml:"plugin_source"`
+	AllowedSources []string       `toml:"allowed_sources"`
+}
+
+// loadPluginSources reads the `[[plugin_source]]` blocks and
+// `allowed_sources` allowlist out of config.toml. Either, or both, may be
+// absent, in which case only Stripe's own manifest is used.
+func loadPluginSources(config config.IConfig, fs afero.Fs) (pluginSourcesConfig, error) {
+	var sources pluginSourcesConfig
+
+	configPath := config.GetConfigFolder(os.Getenv("XDG_CONFIG_HOME"))
+	configFilePath := filepath.Join(configPath, "config.toml")
+
+	file, err := afero.ReadFile(fs, configFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sources, nil
+		}
+
+		return sources, err
+	}
+
+	if _, err := toml.Decode(string(file), &sources); err != nil {
+		return sources, err
+	}
+
+	if err := validateSourceNames(sources); err != nil {
+		return pluginSourcesConfig{}, err
+	}
+
+	if err := validateAllowedSources(sources); err != nil {
+		return pluginSourcesConfig{}, err
+	}
+
+	return sources, nil
+}
+
+// validateSourceNames rejects any `[[plugin_source]]` block whose name
+// collides with a reservedSourceNames entry, before that name is ever used
+// to resolve a cache path or a merge identity.
+func validateSourceNames(sources pluginSourcesConfig) error {
+	for _, source := range sources.PluginSource {
+		if reservedSourceNames[source.Name] {
+			return fmt.Errorf("plugin source name %q is reserved and cannot be used by a plugin_source", source.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateAllowedSources enforces the `allowed_sources` allowlist, if one
+// is configured, by rejecting any `[[plugin_source]]` block whose name is
+// not on it. The allowlist only covers those blocks: Stripe's own manifest
+// is always fetched and used, whether or not it is listed.
+func validateAllowedSources(sources pluginSourcesConfig) error {
+	if len(sources.AllowedSources) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(sources.AllowedSources))
+	for _, name := range sources.AllowedSources {
+		allowed[name] = true
+	}
+
+	for _, source := range sources.PluginSource {
+		if !allowed[source.Name] {
+			return fmt.Errorf("plugin source %q is not in allowed_sources", source.Name)
+		}
+	}
+
+	return nil
+}
+
+// sourceManifestCachePath returns where a given source's plugins.toml is
+// cached on disk. The default Stripe source keeps its historical path of
+// plugins.toml; third-party sources get their own namespaced file so they
+// don't clobber each other.
+func sourceManifestCachePath(configPath, sourceName string) string {
+	if sourceName == defaultSourceName {
+		return filepath.Join(configPath, "plugins.toml")
+	}
+
+	return filepath.Join(configPath, fmt.Sprintf("plugins-%s.toml", sourceName))
+}
+
+// namespacedShortname returns the `source/shortname` form used to
+// disambiguate two sources that both publish a plugin with the same
+// shortname.
+func namespacedShortname(sourceName, shortname string) string {
+	return fmt.Sprintf("%s/%s", sourceName, shortname)
+}
+
+// mergePluginSources merges the default Stripe manifest with every
+// registered third-party source's manifest into a single PluginList. Only
+// the default Stripe source ever keeps a bare shortname; every
+// third-party plugin is namespaced as source/shortname, conflicting or
+// not (see namespacedShortname). When two sources publish the same
+// shortname, the higher-priority one is treated as the winner: a tie
+// between equal priorities is an error rather than a silent pick, since
+// which one "wins" would otherwise depend on map iteration order. Either
+// way, the loser isn't dropped - it stays reachable under its namespaced
+// form, same as any other third-party plugin that happens not to collide
+// with anything. The default source uses defaultSourcePriority rather than
+// 0 so that a third-party source which doesn't bother to set `priority`
+// still cleanly outranks it instead of tying (see defaultSourcePriority).
+func mergePluginSources(defaultList PluginList, thirdParty map[string][]Plugin, sources []PluginSource) (PluginList, error) {
+	priorityByName := make(map[string]int, len(sources))
+	for _, s := range sources {
+		priorityByName[s.Name] = s.Priority
+	}
+
+	type candidate struct {
+		plugin   Plugin
+		source   string
+		priority int
+	}
+
+	bySortname := make(map[string][]candidate)
+
+	add := func(shortname, source string, priority int, plugin Plugin) {
+		bySortname[shortname] = append(bySortname[shortname], candidate{plugin: plugin, source: source, priority: priority})
+	}
+
+	for _, p := range defaultList.Plugins {
+		add(p.Shortname, defaultSourceName, defaultSourcePriority, p)
+	}
+
+	for sourceName, plugins := range thirdParty {
+		priority := priorityByName[sourceName]
+
+		for _, p := range plugins {
+			add(p.Shortname, sourceName, priority, p)
+		}
+	}
+
+	merged := PluginList{}
+
+	for shortname, candidates := range bySortname {
+		winner := candidates[0]
+		tied := false
+
+		for _, c := range candidates[1:] {
+			switch {
+			case c.priority > winner.priority:
+				winner, tied = c, false
+			case c.priority == winner.priority:
+				tied = true
+			}
+		}
+
+		if tied {
+			return PluginList{}, fmt.Errorf("plugin %q is published by multiple sources at the same priority", shortname)
+		}
+
+		for _, c := range candidates {
+			plugin := c.plugin
+
+			// Only the overall winner keeps the bare shortname, and only
+			// if it came from the default Stripe source; every other
+			// candidate (including a losing default-source plugin) is
+			// namespaced so it stays reachable rather than vanishing.
+			if !(c.source == winner.source && c.source == defaultSourceName) {
+				plugin.Shortname = namespacedShortname(c.source, shortname)
+			}
+
+			merged.Plugins = append(merged.Plugins, plugin)
+		}
+	}
+
+	return merged, nil
+}
+
+// resolvePluginSourceKey decodes the hex-encoded ed25519 public key pinned
+// to a plugin source in config.toml.
+func resolvePluginSourceKey(source PluginSource) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(source.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public_key for plugin source %q: %w", source.Name, err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public_key for plugin source %q is not a valid ed25519 key", source.Name)
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// stripSourcePrefix splits a possibly-namespaced `source/shortname` lookup
+// into its source and shortname parts. If name isn't namespaced, source is
+// empty and shortname is the whole name.
+func stripSourcePrefix(name string) (source, shortname string) {
+	if idx := strings.Index(name, "/"); idx != -1 {
+		return name[:idx], name[idx+1:]
+	}
+
+	return "", name
+}
@@ -0,0 +1,188 @@
+package plugins
+
+import "testing"
+
+func TestMergePluginSourcesNoConflict(t *testing.T) {
+	defaultList := PluginList{Plugins: []Plugin{{Shortname: "foo"}}}
+	thirdParty := map[string][]Plugin{
+		"acme": {{Shortname: "bar"}},
+	}
+	sources := []PluginSource{{Name: "acme", Priority: 5}}
+
+	merged, err := mergePluginSources(defaultList, thirdParty, sources)
+	if err != nil {
+		t.Fatalf("mergePluginSources: %v", err)
+	}
+
+	names := shortnames(merged)
+
+	if !names["foo"] {
+		t.Errorf("expected default plugin %q to keep its bare shortname, got %v", "foo", names)
+	}
+	if !names["acme/bar"] {
+		t.Errorf("expected third-party plugin to be namespaced as %q, got %v", "acme/bar", names)
+	}
+}
+
+func TestMergePluginSourcesThirdPartyBeatsDefaultSource(t *testing.T) {
+	defaultList := PluginList{Plugins: []Plugin{{Shortname: "foo"}}}
+	thirdParty := map[string][]Plugin{
+		"acme": {{Shortname: "foo"}},
+	}
+	sources := []PluginSource{{Name: "acme", Priority: 10}}
+
+	merged, err := mergePluginSources(defaultList, thirdParty, sources)
+	if err != nil {
+		t.Fatalf("mergePluginSources: %v", err)
+	}
+
+	names := shortnames(merged)
+
+	// The higher-priority third-party source wins the conflict, but the
+	// bare "foo" name is still reserved for the default Stripe source's
+	// naming convention, so the winner is namespaced too...
+	if !names["acme/foo"] {
+		t.Errorf("expected winning third-party plugin to be namespaced as %q, got %v", "acme/foo", names)
+	}
+
+	// ...and the losing default-source plugin must still be reachable,
+	// not silently dropped.
+	if !names["stripe/foo"] {
+		t.Errorf("expected losing default-source plugin to remain reachable as %q, got %v", "stripe/foo", names)
+	}
+}
+
+func TestMergePluginSourcesLoserRetainedUnderNamespace(t *testing.T) {
+	thirdParty := map[string][]Plugin{
+		"acme":    {{Shortname: "foo"}},
+		"contoso": {{Shortname: "foo"}},
+	}
+	sources := []PluginSource{
+		{Name: "acme", Priority: 10},
+		{Name: "contoso", Priority: 1},
+	}
+
+	merged, err := mergePluginSources(PluginList{}, thirdParty, sources)
+	if err != nil {
+		t.Fatalf("mergePluginSources: %v", err)
+	}
+
+	names := shortnames(merged)
+
+	if !names["acme/foo"] {
+		t.Errorf("expected winning plugin to be reachable as %q, got %v", "acme/foo", names)
+	}
+	if !names["contoso/foo"] {
+		t.Errorf("expected losing plugin to remain reachable as %q instead of being dropped, got %v", "contoso/foo", names)
+	}
+}
+
+func TestMergePluginSourcesUnsetPriorityBeatsDefaultSourceWithoutTying(t *testing.T) {
+	defaultList := PluginList{Plugins: []Plugin{{Shortname: "foo"}}}
+	thirdParty := map[string][]Plugin{
+		"acme": {{Shortname: "foo"}},
+	}
+	// acme doesn't set a priority at all, which decodes to the Go zero
+	// value, 0. That must still cleanly beat the default source rather
+	// than ever being mistaken for a tie against it.
+	sources := []PluginSource{{Name: "acme"}}
+
+	merged, err := mergePluginSources(defaultList, thirdParty, sources)
+	if err != nil {
+		t.Fatalf("mergePluginSources: %v", err)
+	}
+
+	names := shortnames(merged)
+	if !names["acme/foo"] {
+		t.Errorf("expected unset-priority third-party plugin to win and be namespaced as %q, got %v", "acme/foo", names)
+	}
+	if !names["stripe/foo"] {
+		t.Errorf("expected losing default-source plugin to remain reachable as %q, got %v", "stripe/foo", names)
+	}
+}
+
+func TestMergePluginSourcesTiePriorityIsError(t *testing.T) {
+	thirdParty := map[string][]Plugin{
+		"acme":    {{Shortname: "foo"}},
+		"contoso": {{Shortname: "foo"}},
+	}
+	sources := []PluginSource{
+		{Name: "acme", Priority: 5},
+		{Name: "contoso", Priority: 5},
+	}
+
+	if _, err := mergePluginSources(PluginList{}, thirdParty, sources); err == nil {
+		t.Error("expected a tied-priority conflict to be an error, got nil")
+	}
+}
+
+func TestStripSourcePrefix(t *testing.T) {
+	tests := []struct {
+		name          string
+		wantSource    string
+		wantShortname string
+	}{
+		{name: "foo", wantSource: "", wantShortname: "foo"},
+		{name: "acme/foo", wantSource: "acme", wantShortname: "foo"},
+		{name: "acme/foo/bar", wantSource: "acme", wantShortname: "foo/bar"},
+	}
+
+	for _, tt := range tests {
+		source, shortname := stripSourcePrefix(tt.name)
+		if source != tt.wantSource || shortname != tt.wantShortname {
+			t.Errorf("stripSourcePrefix(%q) = (%q, %q), want (%q, %q)", tt.name, source, shortname, tt.wantSource, tt.wantShortname)
+		}
+	}
+}
+
+func TestValidateAllowedSources(t *testing.T) {
+	noAllowlist := pluginSourcesConfig{
+		PluginSource: []PluginSource{{Name: "acme"}},
+	}
+	if err := validateAllowedSources(noAllowlist); err != nil {
+		t.Errorf("expected no allowlist to permit anything, got %v", err)
+	}
+
+	allowed := pluginSourcesConfig{
+		PluginSource:   []PluginSource{{Name: "acme"}},
+		AllowedSources: []string{"acme"},
+	}
+	if err := validateAllowedSources(allowed); err != nil {
+		t.Errorf("expected an allowlisted source to pass, got %v", err)
+	}
+
+	disallowed := pluginSourcesConfig{
+		PluginSource:   []PluginSource{{Name: "evil-corp"}},
+		AllowedSources: []string{"acme"},
+	}
+	if err := validateAllowedSources(disallowed); err == nil {
+		t.Error("expected a non-allowlisted source to be rejected, got nil")
+	}
+}
+
+func TestValidateSourceNamesRejectsReservedName(t *testing.T) {
+	sources := pluginSourcesConfig{
+		PluginSource: []PluginSource{{Name: defaultSourceName}},
+	}
+
+	if err := validateSourceNames(sources); err == nil {
+		t.Error("expected a plugin_source named the reserved default source name to be rejected, got nil")
+	}
+
+	ok := pluginSourcesConfig{
+		PluginSource: []PluginSource{{Name: "acme"}},
+	}
+
+	if err := validateSourceNames(ok); err != nil {
+		t.Errorf("expected a non-reserved source name to pass, got %v", err)
+	}
+}
+
+func shortnames(list PluginList) map[string]bool {
+	names := make(map[string]bool, len(list.Plugins))
+	for _, p := range list.Plugins {
+		names[p.Shortname] = true
+	}
+
+	return names
+}
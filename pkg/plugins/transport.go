@@ -0,0 +1,89 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ManifestTransport fetches a plugin manifest, its signature, and its
+// binaries from wherever a plugin source's URL points. Which
+// implementation is used is selected off the URL scheme, so a plugin
+// source can point at an HTTPS endpoint or a mounted directory without any
+// other code needing to know the difference.
+type ManifestTransport interface {
+	// FetchManifest returns the raw bytes of plugins.toml for this source.
+	FetchManifest(ctx context.Context) ([]byte, error)
+	// FetchSignature returns the raw bytes of plugins.toml.sig for this
+	// source.
+	FetchSignature(ctx context.Context) ([]byte, error)
+	// FetchBinary streams the named plugin binary to w.
+	FetchBinary(ctx context.Context, name string, w io.Writer) error
+}
+
+// newManifestTransport resolves the ManifestTransport to use for a plugin
+// source's URL, based on its scheme.
+func newManifestTransport(sourceURL string) (ManifestTransport, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin source url %q: %w", sourceURL, err)
+	}
+
+	switch u.Scheme {
+	case "", "https", "http":
+		return &httpsManifestTransport{baseURL: sourceURL}, nil
+	case "file":
+		return &fileManifestTransport{dir: u.Path}, nil
+	default:
+		return nil, fmt.Errorf("unsupported plugin source scheme %q", u.Scheme)
+	}
+}
+
+// httpsManifestTransport is the original, and still default, transport:
+// plugins.toml, its signature, and every binary are plain HTTPS downloads.
+type httpsManifestTransport struct {
+	baseURL string
+}
+
+func (t *httpsManifestTransport) FetchManifest(ctx context.Context) ([]byte, error) {
+	return FetchRemoteResource(fmt.Sprintf("%s/%s", t.baseURL, "plugins.toml"))
+}
+
+func (t *httpsManifestTransport) FetchSignature(ctx context.Context) ([]byte, error) {
+	return FetchRemoteResource(fmt.Sprintf("%s/%s", t.baseURL, "plugins.toml.sig"))
+}
+
+func (t *httpsManifestTransport) FetchBinary(ctx context.Context, name string, w io.Writer) error {
+	return FetchRemoteResourceTo(fmt.Sprintf("%s/%s", t.baseURL, name), w)
+}
+
+// fileManifestTransport reads a manifest, its signature, and its binaries
+// off a mounted directory, e.g. `file:///mnt/stripe-plugins`. This is the
+// transport a third-party source configured for an air-gapped mirror ends
+// up using.
+type fileManifestTransport struct {
+	dir string
+}
+
+func (t *fileManifestTransport) FetchManifest(ctx context.Context) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(t.dir, "plugins.toml"))
+}
+
+func (t *fileManifestTransport) FetchSignature(ctx context.Context) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(t.dir, "plugins.toml.sig"))
+}
+
+func (t *fileManifestTransport) FetchBinary(ctx context.Context, binaryPath string, w io.Writer) error {
+	f, err := os.Open(filepath.Join(t.dir, binaryPath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,124 @@
+package plugins
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewManifestTransport(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string // Go type name of the expected transport
+		wantErr bool
+	}{
+		{url: "https://plugins.stripe.com", want: "*plugins.httpsManifestTransport"},
+		{url: "http://plugins.internal", want: "*plugins.httpsManifestTransport"},
+		{url: "plugins.stripe.com/no-scheme", want: "*plugins.httpsManifestTransport"},
+		{url: "file:///mnt/stripe-plugins", want: "*plugins.fileManifestTransport"},
+		{url: "oci://registry.internal/stripe-plugins", wantErr: true},
+		{url: "ftp://old-school.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		transport, err := newManifestTransport(tt.url)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("newManifestTransport(%q): expected an error, got none", tt.url)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("newManifestTransport(%q): unexpected error: %v", tt.url, err)
+			continue
+		}
+
+		switch tt.want {
+		case "*plugins.httpsManifestTransport":
+			if _, ok := transport.(*httpsManifestTransport); !ok {
+				t.Errorf("newManifestTransport(%q) = %T, want httpsManifestTransport", tt.url, transport)
+			}
+		case "*plugins.fileManifestTransport":
+			if _, ok := transport.(*fileManifestTransport); !ok {
+				t.Errorf("newManifestTransport(%q) = %T, want fileManifestTransport", tt.url, transport)
+			}
+		}
+	}
+}
+
+func TestHTTPSManifestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plugins.toml":
+			w.Write([]byte("plugins = []"))
+		case "/plugins.toml.sig":
+			w.Write([]byte("signature-bytes"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	transport, err := newManifestTransport(server.URL)
+	if err != nil {
+		t.Fatalf("newManifestTransport: %v", err)
+	}
+
+	manifest, err := transport.FetchManifest(context.Background())
+	if err != nil {
+		t.Fatalf("FetchManifest: %v", err)
+	}
+	if string(manifest) != "plugins = []" {
+		t.Errorf("FetchManifest = %q, want %q", manifest, "plugins = []")
+	}
+
+	sig, err := transport.FetchSignature(context.Background())
+	if err != nil {
+		t.Fatalf("FetchSignature: %v", err)
+	}
+	if string(sig) != "signature-bytes" {
+		t.Errorf("FetchSignature = %q, want %q", sig, "signature-bytes")
+	}
+}
+
+func TestFileManifestTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins-file-transport")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "plugins.toml"), []byte("plugins = []"), 0644); err != nil {
+		t.Fatalf("writing manifest fixture: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "plugins.toml.sig"), []byte("signature-bytes"), 0644); err != nil {
+		t.Fatalf("writing signature fixture: %v", err)
+	}
+
+	transport, err := newManifestTransport("file://" + dir)
+	if err != nil {
+		t.Fatalf("newManifestTransport: %v", err)
+	}
+
+	manifest, err := transport.FetchManifest(context.Background())
+	if err != nil {
+		t.Fatalf("FetchManifest: %v", err)
+	}
+	if string(manifest) != "plugins = []" {
+		t.Errorf("FetchManifest = %q, want %q", manifest, "plugins = []")
+	}
+
+	sig, err := transport.FetchSignature(context.Background())
+	if err != nil {
+		t.Fatalf("FetchSignature: %v", err)
+	}
+	if string(sig) != "signature-bytes" {
+		t.Errorf("FetchSignature = %q, want %q", sig, "signature-bytes")
+	}
+}
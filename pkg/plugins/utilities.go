@@ -1,14 +1,16 @@
 package plugins
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/http/httptrace"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -55,6 +57,11 @@ func GetPluginList(ctx context.Context, config config.IConfig, fs afero.Fs) (Plu
 	var pluginList PluginList
 	configPath := config.GetConfigFolder(os.Getenv("XDG_CONFIG_HOME"))
 	pluginManifestPath := filepath.Join(configPath, "plugins.toml")
+	imported := hasImportedBundle(config, fs)
+
+	if imported {
+		pluginManifestPath = filepath.Join(bundleDir(config), bundleManifestEntry)
+	}
 
 	file, err := afero.ReadFile(fs, pluginManifestPath)
 	if os.IsNotExist(err) {
@@ -76,10 +83,93 @@ func GetPluginList(ctx context.Context, config config.IConfig, fs afero.Fs) (Plu
 		return pluginList, err
 	}
 
+	sources, err := loadPluginSources(config, fs)
+	if err != nil {
+		return pluginList, err
+	}
+
+	if len(sources.PluginSource) > 0 {
+		// A bundle import promises to make the CLI work fully offline (see
+		// bundleDir's doc comment), and a bundle tarball never packages
+		// third-party source manifests in the first place - so a missing
+		// one can't be self-healed here without breaking that promise, the
+		// same reason RefreshPluginManifest no-ops entirely once a bundle
+		// has been imported. It's left to fail below with a clear error
+		// instead.
+		if !imported {
+			if missing := missingSourceManifests(fs, configPath, sources.PluginSource); len(missing) > 0 {
+				log.Debugf("no cached manifest for plugin source(s) %v, refreshing", missing)
+
+				// Fetched directly through refreshThirdPartyManifests, not
+				// RefreshPluginManifest, so this doesn't also refetch the
+				// default Stripe manifest, and is scoped to the missing
+				// sources only so adding one new source doesn't force a
+				// refetch of every other source's already-cached manifest.
+				if err := refreshThirdPartyManifests(ctx, config, fs, configPath, missing); err != nil {
+					return pluginList, fmt.Errorf("could not fetch manifest for plugin source(s) %v: %w", missing, err)
+				}
+			}
+		}
+
+		thirdParty := make(map[string][]Plugin, len(sources.PluginSource))
+
+		for _, source := range sources.PluginSource {
+			var sourceList PluginList
+
+			sourceManifestPath := sourceManifestCachePath(configPath, source.Name)
+
+			sourceFile, err := afero.ReadFile(fs, sourceManifestPath)
+			if err != nil {
+				return pluginList, fmt.Errorf("could not read manifest for plugin source %q: %w", source.Name, err)
+			}
+
+			if _, err := toml.Decode(string(sourceFile), &sourceList); err != nil {
+				return pluginList, err
+			}
+
+			thirdParty[source.Name] = sourceList.Plugins
+		}
+
+		pluginList, err = mergePluginSources(pluginList, thirdParty, sources.PluginSource)
+		if err != nil {
+			return pluginList, err
+		}
+	}
+
+	discovered, err := discoverLocalPlugins(config, fs)
+	if err != nil {
+		return pluginList, err
+	}
+
+	pluginList.Plugins = append(pluginList.Plugins, discovered...)
+
 	return pluginList, nil
 }
 
-// LookUpPlugin returns the matching plugin object
+// missingSourceManifests returns the name of every plugin source in sources
+// whose cached manifest file (see sourceManifestCachePath) doesn't exist on
+// fs yet, e.g. a plugin_source the user just added to config.toml that
+// GetPluginList has never fetched. Unlike the default manifest, which has
+// always self-healed by refreshing on first read, a missing third-party
+// manifest used to surface as a bare "could not read manifest" os.PathError
+// instead.
+func missingSourceManifests(fs afero.Fs, configPath string, sources []PluginSource) []string {
+	var missing []string
+
+	for _, source := range sources {
+		path := sourceManifestCachePath(configPath, source.Name)
+
+		if exists, err := afero.Exists(fs, path); err != nil || !exists {
+			missing = append(missing, source.Name)
+		}
+	}
+
+	return missing
+}
+
+// LookUpPlugin returns the matching plugin object. pluginName may be a bare
+// shortname, or a `source/shortname` form used to disambiguate two sources
+// that both publish a plugin under the same shortname.
 func LookUpPlugin(ctx context.Context, config config.IConfig, fs afero.Fs, pluginName string) (Plugin, error) {
 	var plugin Plugin
 	pluginList, err := GetPluginList(ctx, config, fs)
@@ -93,11 +183,39 @@ func LookUpPlugin(ctx context.Context, config config.IConfig, fs afero.Fs, plugi
 		}
 	}
 
+	// Fall back to matching the bare shortname against a namespaced entry,
+	// e.g. "foo" against "myorg/foo", when the lookup isn't ambiguous.
+	var match Plugin
+
+	found := false
+
+	for _, p := range pluginList.Plugins {
+		_, shortname := stripSourcePrefix(p.Shortname)
+		if shortname != pluginName {
+			continue
+		}
+
+		if found {
+			return plugin, fmt.Errorf("plugin name %q is ambiguous across multiple sources, use the source/shortname form", pluginName)
+		}
+
+		match, found = p, true
+	}
+
+	if found {
+		return match, nil
+	}
+
 	return plugin, fmt.Errorf("Could not find a plugin named %s", pluginName)
 }
 
 // RefreshPluginManifest refreshes the plugin manifest
 func RefreshPluginManifest(ctx context.Context, config config.IConfig, fs afero.Fs, baseURL string) error {
+	if hasImportedBundle(config, fs) {
+		log.Debug("Plugin bundle imported, skipping remote manifest refresh")
+		return nil
+	}
+
 	apiKey, err := config.GetProfile().GetAPIKey(false)
 	if err != nil {
 		return err
@@ -109,11 +227,27 @@ func RefreshPluginManifest(ctx context.Context, config config.IConfig, fs afero.
 	}
 
 	pluginManifestURL := fmt.Sprintf("%s/%s", pluginData.PluginBaseURL, "plugins.toml")
-	body, err := FetchRemoteResource(pluginManifestURL)
+
+	cacheDir := pluginCacheDir(config)
+
+	body, notModified, err := fetchManifestCached(ctx, fs, cacheDir, pluginManifestURL)
+	if err != nil {
+		return err
+	}
+
+	if notModified {
+		log.Debug("Plugin manifest not modified since last refresh, using cached copy")
+	}
+
+	sig, err := FetchRemoteResource(pluginManifestURL + ".sig")
 	if err != nil {
 		return err
 	}
 
+	if err := verifyManifestSignature(body, sig); err != nil {
+		return err
+	}
+
 	configPath := config.GetConfigFolder(os.Getenv("XDG_CONFIG_HOME"))
 	pluginManifestPath := filepath.Join(configPath, "plugins.toml")
 
@@ -123,22 +257,235 @@ func RefreshPluginManifest(ctx context.Context, config config.IConfig, fs afero.
 		return err
 	}
 
+	return refreshThirdPartyManifests(ctx, config, fs, configPath, nil)
+}
+
+// refreshThirdPartyManifests downloads and verifies the plugins.toml for
+// every registered third-party plugin source, caching each one next to
+// Stripe's own manifest. Which transport is used to reach a source is
+// selected off its URL scheme, so a source can point at an HTTPS endpoint
+// or a mounted directory interchangeably.
+//
+// only, if non-nil, restricts the refresh to sources named in it - used by
+// GetPluginList's self-heal path so that adding one new plugin_source
+// doesn't force a refetch of every other source's already-cached manifest.
+// A nil only refreshes every registered source, e.g. RefreshPluginManifest's
+// full refresh.
+func refreshThirdPartyManifests(ctx context.Context, config config.IConfig, fs afero.Fs, configPath string, only []string) error {
+	sources, err := loadPluginSources(config, fs)
+	if err != nil {
+		return err
+	}
+
+	wanted := stringSet(only)
+
+	for _, source := range sources.PluginSource {
+		if wanted != nil && !wanted[source.Name] {
+			continue
+		}
+
+		pubKey, err := resolvePluginSourceKey(source)
+		if err != nil {
+			return err
+		}
+
+		transport, err := newManifestTransport(source.URL)
+		if err != nil {
+			return fmt.Errorf("plugin source %q: %w", source.Name, err)
+		}
+
+		body, err := transport.FetchManifest(ctx)
+		if err != nil {
+			return fmt.Errorf("could not fetch manifest for plugin source %q: %w", source.Name, err)
+		}
+
+		sig, err := transport.FetchSignature(ctx)
+		if err != nil {
+			return fmt.Errorf("could not fetch signature for plugin source %q: %w", source.Name, err)
+		}
+
+		if err := verifyManifestSignatureWithKey(pubKey, body, sig); err != nil {
+			return fmt.Errorf("plugin source %q: %w", source.Name, err)
+		}
+
+		if err := afero.WriteFile(fs, sourceManifestCachePath(configPath, source.Name), body, 0644); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// FetchRemoteResource returns the remote resource body
+// stringSet returns items as a lookup set, or nil if items is nil - the
+// nil case lets a caller distinguish "no filter" from "filter matching
+// nothing" via a single map value.
+func stringSet(items []string) map[string]bool {
+	if items == nil {
+		return nil
+	}
+
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+
+	return set
+}
+
+// fetchTimeout bounds a single attempt at fetching a remote resource.
+// Retries get their own fresh budget rather than sharing one deadline, so a
+// slow-but-successful attempt doesn't get starved by an earlier failed one.
+const fetchTimeout = 15 * time.Second
+
+// fetchMaxRetries is how many additional attempts are made after a 5xx
+// response or network error, with exponential backoff between them.
+const fetchMaxRetries = 3
+
+// FetchRemoteResource returns the remote resource body. It's a thin
+// backward-compatible wrapper around FetchRemoteResourceTo for callers
+// that want the whole body in memory.
 func FetchRemoteResource(url string) ([]byte, error) {
-	t := &requests.TracedTransport{}
+	var buf bytes.Buffer
+
+	if err := FetchRemoteResourceTo(url, &buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// FetchRemoteResourceTo streams the remote resource at url into w instead
+// of buffering it in memory, so large plugin binaries can be written
+// straight to disk. It retries 5xx responses and network errors with
+// exponential backoff.
+func FetchRemoteResourceTo(url string, w io.Writer) error {
+	_, err := fetchWithRetry(context.Background(), url, nil, w)
+	return err
+}
+
+// fetchWithRetry performs a conditional GET against url, retrying 5xx
+// responses and network errors with exponential backoff. headers, if
+// non-nil, are added to the request (used for If-None-Match /
+// If-Modified-Since). It streams a 2xx body into w and returns the
+// response so callers can inspect status and caching headers; on a 304 the
+// body is empty and w is left untouched.
+func fetchWithRetry(ctx context.Context, url string, headers map[string]string, w io.Writer) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= fetchMaxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
+			log.Debugf("retrying %s in %s (attempt %d/%d): %v", url, backoff, attempt, fetchMaxRetries, lastErr)
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		resp, err := doFetch(ctx, url, headers, w)
+		if err == nil {
+			return resp, nil
+		}
+
+		lastErr = err
+
+		if !isRetryableFetchError(err) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("giving up on %s after %d attempts: %w", url, fetchMaxRetries+1, lastErr)
+}
+
+// retryableStatusError marks a non-2xx HTTP response that's worth retrying.
+type retryableStatusError struct {
+	StatusCode int
+}
+
+func (e *retryableStatusError) Error() string {
+	return fmt.Sprintf("server returned %d", e.StatusCode)
+}
+
+func isRetryableFetchError(err error) bool {
+	if _, ok := err.(*retryableStatusError); ok {
+		return true
+	}
+
+	if _, ok := err.(*nonRetryableStatusError); ok {
+		return false
+	}
+
+	// A failure while copying the body means some of it may already have
+	// been written to w; since w isn't guaranteed to be seekable (e.g. a
+	// destination file), retrying would risk appending a second copy on
+	// top of a partial write, so this is left to the caller to retry from
+	// scratch if it wants to.
+	if _, ok := err.(*bodyCopyError); ok {
+		return false
+	}
+
+	// Anything else reaching here came from the transport before any body
+	// bytes were written (DNS failure, connection reset, timeout, ...),
+	// which is safe to retry.
+	return true
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// nonRetryableStatusError marks a non-2xx HTTP response (e.g. 404, 401)
+// that retrying won't fix.
+type nonRetryableStatusError struct {
+	StatusCode int
+}
+
+func (e *nonRetryableStatusError) Error() string {
+	return fmt.Sprintf("server returned %d", e.StatusCode)
+}
+
+// bodyCopyError wraps a failure that happened partway through copying a
+// response body into w, after some bytes may already have been written.
+type bodyCopyError struct {
+	err error
+}
 
+func (e *bodyCopyError) Error() string { return e.err.Error() }
+func (e *bodyCopyError) Unwrap() error { return e.err }
+
+// doFetch makes a single attempt at fetching url, applying fetchTimeout and
+// logging the DNS/connect timings captured by requests.TracedTransport.
+func doFetch(ctx context.Context, url string, headers map[string]string, w io.Writer) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	t := &requests.TracedTransport{}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	var dnsStart, connectStart time.Time
+	var dnsDuration, connectDuration time.Duration
+
 	trace := &httptrace.ClientTrace{
-		GotConn: t.GotConn,
-		DNSDone: t.DNSDone,
+		GotConn:      t.GotConn,
+		DNSStart:     func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(network, addr string, err error) {
+			if !connectStart.IsZero() {
+				connectDuration = time.Since(connectStart)
+			}
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.DNSDone(info)
+			if !dnsStart.IsZero() {
+				dnsDuration = time.Since(dnsStart)
+			}
+		},
 	}
 
 	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
@@ -146,20 +493,82 @@ func FetchRemoteResource(url string) ([]byte, error) {
 	client := &http.Client{Transport: t}
 
 	resp, err := client.Do(req)
-
 	if err != nil {
 		return nil, err
 	}
-
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	log.Debugf("fetched %s: status=%d dns=%s connect=%s", url, resp.StatusCode, dnsDuration, connectDuration)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return resp, nil
+	case resp.StatusCode >= 500:
+		return nil, &retryableStatusError{StatusCode: resp.StatusCode}
+	case resp.StatusCode >= 400:
+		return nil, &nonRetryableStatusError{StatusCode: resp.StatusCode}
+	}
 
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return nil, &bodyCopyError{err: err}
+	}
+
+	return resp, nil
+}
+
+// fetchManifestCached fetches url with conditional headers built from the
+// cache under cacheDir, so an unchanged manifest comes back as a cheap 304
+// instead of a full re-download. It returns the manifest body (freshly
+// fetched, or served from cache on a 304) and whether the cache was used.
+func fetchManifestCached(ctx context.Context, fs afero.Fs, cacheDir, url string) ([]byte, bool, error) {
+	meta, hasCache := loadManifestCacheMeta(fs, cacheDir, url)
+
+	headers := map[string]string{}
+	if hasCache {
+		if meta.ETag != "" {
+			headers["If-None-Match"] = meta.ETag
+		}
+		if meta.LastModified != "" {
+			headers["If-Modified-Since"] = meta.LastModified
+		}
+	}
+
+	var buf bytes.Buffer
+
+	resp, err := fetchWithRetry(ctx, url, headers, &buf)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		body, err := loadManifestCacheBody(fs, cacheDir, url)
+		if err == nil {
+			return body, true, nil
+		}
+
+		// The server thinks we already have the latest copy, but our local
+		// cache of it is gone; fall back to an unconditional fetch rather
+		// than failing the whole refresh.
+		log.Debugf("cached manifest body for %s is unreadable, re-fetching unconditionally: %v", url, err)
+
+		buf.Reset()
+
+		resp, err = fetchWithRetry(ctx, url, nil, &buf)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	newMeta := manifestCacheMeta{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	if err := saveManifestCacheMeta(fs, cacheDir, url, newMeta, buf.Bytes()); err != nil {
+		log.Debugf("could not persist manifest cache for %s: %v", url, err)
 	}
 
-	return body, nil
+	return buf.Bytes(), false, nil
 }
 
 // CleanupAllClients tears down and disconnects all "managed" plugin clients
@@ -0,0 +1,38 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestMissingSourceManifests(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	configPath := "/config"
+
+	sources := []PluginSource{{Name: "acme"}, {Name: "contoso"}}
+
+	if err := afero.WriteFile(fs, sourceManifestCachePath(configPath, "acme"), []byte("plugins = []"), 0644); err != nil {
+		t.Fatalf("writing acme manifest: %v", err)
+	}
+
+	missing := missingSourceManifests(fs, configPath, sources)
+	if len(missing) != 1 || missing[0] != "contoso" {
+		t.Errorf("missingSourceManifests = %v, want [contoso]", missing)
+	}
+
+	if got := missingSourceManifests(fs, configPath, nil); got != nil {
+		t.Errorf("missingSourceManifests with no sources = %v, want nil", got)
+	}
+}
+
+func TestStringSet(t *testing.T) {
+	if got := stringSet(nil); got != nil {
+		t.Errorf("stringSet(nil) = %v, want nil", got)
+	}
+
+	set := stringSet([]string{"acme", "contoso"})
+	if !set["acme"] || !set["contoso"] || set["evil-corp"] {
+		t.Errorf("stringSet([acme, contoso]) = %v, want a set containing exactly acme and contoso", set)
+	}
+}
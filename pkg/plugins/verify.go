@@ -0,0 +1,292 @@
+package plugins
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/afero"
+
+	"github.com/stripe/stripe-cli/pkg/config"
+)
+
+// manifestPublicKeyHex is the hex-encoded public half of the offline
+// release key used to sign plugins.toml. It is empty in a dev build; a
+// release build pins it with:
+//
+//	go build -ldflags "-X github.com/stripe/stripe-cli/pkg/plugins.manifestPublicKeyHex=<hex>"
+//
+// so the private half never needs to touch this source tree.
+var manifestPublicKeyHex string
+
+// ErrManifestSignatureInvalid is returned when a downloaded plugins.toml
+// does not validate against the compiled-in release key.
+var ErrManifestSignatureInvalid = fmt.Errorf("plugin manifest signature is invalid")
+
+// ErrDigestMismatch is returned when a downloaded or installed plugin
+// binary's digest does not match the one pinned in the manifest.
+var ErrDigestMismatch = fmt.Errorf("plugin binary digest does not match manifest")
+
+// errManifestVerificationUnavailable is returned when no release key has
+// been compiled in, so there's nothing to check a signature against.
+var errManifestVerificationUnavailable = fmt.Errorf("no plugin manifest release key compiled in")
+
+// manifestPublicKey decodes manifestPublicKeyHex, if one was compiled in.
+func manifestPublicKey() (ed25519.PublicKey, error) {
+	if manifestPublicKeyHex == "" {
+		return nil, errManifestVerificationUnavailable
+	}
+
+	key, err := hex.DecodeString(manifestPublicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compiled-in plugin manifest release key: %w", err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("compiled-in plugin manifest release key is not a valid ed25519 key")
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// verifyManifestSignature checks that sig is a valid ed25519 signature of
+// manifest produced by the pinned release key. Until a release build pins
+// a real key via manifestPublicKeyHex, this is a no-op: rejecting every
+// manifest against a placeholder key would be worse than not checking at
+// all, so verification stays off until there's a real key to check against.
+func verifyManifestSignature(manifest, sig []byte) error {
+	key, err := manifestPublicKey()
+	if err == errManifestVerificationUnavailable {
+		log.Warnf("skipping plugin manifest signature verification: %v", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return verifyManifestSignatureWithKey(key, manifest, sig)
+}
+
+// verifyManifestSignatureWithKey checks that sig is a valid ed25519
+// signature of manifest produced by the given public key. This is used to
+// verify manifests from third-party plugin sources, each of which is
+// pinned to its own key rather than the default release key.
+func verifyManifestSignatureWithKey(pubKey ed25519.PublicKey, manifest, sig []byte) error {
+	if !ed25519.Verify(pubKey, manifest, sig) {
+		return ErrManifestSignatureInvalid
+	}
+
+	return nil
+}
+
+// digestPath returns the path the digest for an installed plugin binary is
+// cached at, alongside the binary itself.
+func digestPath(binaryPath string) string {
+	return binaryPath + ".sha256"
+}
+
+// hashingWriter forwards writes to an underlying io.Writer while feeding
+// them through a running SHA-256 digest, so a download can be hashed as
+// it is streamed to disk instead of buffered in memory first.
+type hashingWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+func newHashingWriter(w io.Writer) *hashingWriter {
+	return &hashingWriter{w: w, h: sha256.New()}
+}
+
+func (hw *hashingWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	if n > 0 {
+		_, _ = hw.h.Write(p[:n])
+	}
+
+	return n, err
+}
+
+func (hw *hashingWriter) Sum() string {
+	return hex.EncodeToString(hw.h.Sum(nil))
+}
+
+// releaseForPlatform finds the Release entry matching the running OS/arch.
+func releaseForPlatform(plugin Plugin) (Release, error) {
+	for _, r := range plugin.Releases {
+		if r.OS == runtime.GOOS && r.Arch == runtime.GOARCH {
+			return r, nil
+		}
+	}
+
+	return Release{}, fmt.Errorf("no release of plugin %s found for %s/%s", plugin.Shortname, runtime.GOOS, runtime.GOARCH)
+}
+
+// pluginBinaryPath resolves where a plugin's binary lives on disk. A
+// locally discovered plugin already knows its absolute location
+// (BinaryPath); everything else is a manifest-sourced plugin, resolved
+// from getPluginsDir + Shortname + Binary. Shortname comes from a
+// downloaded (and possibly third-party) manifest, so it's validated as a
+// safe path component first - otherwise a malicious "../../etc" shortname
+// could write or read outside the plugins directory, the same tar-slip
+// class of bug ImportBundle guards against for bundle entries.
+func pluginBinaryPath(cfg config.IConfig, plugin Plugin) (string, error) {
+	if plugin.BinaryPath != "" {
+		return plugin.BinaryPath, nil
+	}
+
+	if !isSafePluginPathComponent(plugin.Shortname) {
+		return "", fmt.Errorf("plugin shortname %q is not a valid path component", plugin.Shortname)
+	}
+
+	binaryName := plugin.Binary + GetBinaryExtension()
+
+	return filepath.Join(getPluginsDir(cfg), plugin.Shortname, binaryName), nil
+}
+
+// isSafePluginPathComponent reports whether name is safe to join onto the
+// plugins directory. Shortname is normally a bare name, but a namespaced
+// third-party plugin (source/shortname, see namespacedShortname) legitimately
+// contains a single "/", so this rejects ".." segments and absolute paths
+// rather than rejecting "/" outright.
+func isSafePluginPathComponent(name string) bool {
+	if name == "" || filepath.IsAbs(name) {
+		return false
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// downloadPluginBinary streams the given plugin release to dst, verifying
+// that its SHA-256 digest matches the one declared in the manifest. On a
+// mismatch it returns ErrDigestMismatch and records no digest; it does not
+// remove what was written to dst (InstallPlugin removes that file).
+func downloadPluginBinary(fs afero.Fs, dst afero.File, binaryPath, url string, release Release) error {
+	hw := newHashingWriter(dst)
+
+	if err := FetchRemoteResourceTo(url, hw); err != nil {
+		return err
+	}
+
+	got := hw.Sum()
+	if got != release.Sum {
+		return fmt.Errorf("%w: expected %s, got %s", ErrDigestMismatch, release.Sum, got)
+	}
+
+	return storeDigest(fs, binaryPath, got)
+}
+
+// InstallPlugin downloads the named plugin's binary for the current
+// platform and writes it into the plugins directory, streaming the
+// download through a hashing writer so a corrupted or tampered download is
+// never left on disk under a matching name. baseURL is the plugin
+// manifest's base URL, e.g. pluginData.PluginBaseURL as passed to
+// RefreshPluginManifest.
+func InstallPlugin(ctx context.Context, cfg config.IConfig, fs afero.Fs, baseURL, pluginName string) error {
+	plugin, err := LookUpPlugin(ctx, cfg, fs, pluginName)
+	if err != nil {
+		return err
+	}
+
+	release, err := releaseForPlatform(plugin)
+	if err != nil {
+		return err
+	}
+
+	binaryPath, err := pluginBinaryPath(cfg, plugin)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.MkdirAll(filepath.Dir(binaryPath), 0755); err != nil {
+		return err
+	}
+
+	dst, err := fs.Create(binaryPath)
+	if err != nil {
+		return err
+	}
+
+	binaryName := plugin.Binary + GetBinaryExtension()
+	downloadURL := fmt.Sprintf("%s/%s/%s", baseURL, plugin.Shortname, binaryName)
+
+	if err := downloadPluginBinary(fs, dst, binaryPath, downloadURL, release); err != nil {
+		dst.Close()
+		_ = fs.Remove(binaryPath)
+
+		return err
+	}
+
+	return dst.Close()
+}
+
+// storeDigest persists the digest of a freshly installed plugin binary so
+// VerifyInstalledPlugin can later detect on-disk tampering or bit rot
+// without re-downloading anything.
+func storeDigest(fs afero.Fs, binaryPath, digest string) error {
+	return afero.WriteFile(fs, digestPath(binaryPath), []byte(digest), 0644)
+}
+
+// VerifyInstalledPlugin re-hashes an installed plugin binary and compares it
+// against the digest recorded at install time, refusing to vouch for a
+// binary whose digest has drifted. It backs the `stripe plugin verify`
+// command.
+func VerifyInstalledPlugin(ctx context.Context, cfg config.IConfig, fs afero.Fs, pluginName string) error {
+	plugin, err := LookUpPlugin(ctx, cfg, fs, pluginName)
+	if err != nil {
+		return err
+	}
+
+	release, err := releaseForPlatform(plugin)
+	if err != nil {
+		return err
+	}
+
+	binaryPath, err := pluginBinaryPath(cfg, plugin)
+	if err != nil {
+		return err
+	}
+
+	wantRaw, err := afero.ReadFile(fs, digestPath(binaryPath))
+	if err != nil {
+		return fmt.Errorf("no recorded digest for %s, cannot verify: %w", pluginName, err)
+	}
+	want := string(wantRaw)
+
+	f, err := fs.Open(binaryPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	got := hex.EncodeToString(h.Sum(nil))
+
+	if got != want {
+		return fmt.Errorf("%w: recorded %s, on-disk binary is %s", ErrDigestMismatch, want, got)
+	}
+
+	if want != release.Sum {
+		log.Debugf("recorded digest for %s no longer matches the manifest; the plugin should be reinstalled", pluginName)
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package plugins
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestVerifyManifestSignatureWithKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+
+	manifest := []byte("plugins = []")
+	sig := ed25519.Sign(priv, manifest)
+
+	if err := verifyManifestSignatureWithKey(pub, manifest, sig); err != nil {
+		t.Errorf("expected valid signature to verify, got %v", err)
+	}
+
+	tampered := append([]byte{}, manifest...)
+	tampered[0] = 'X'
+
+	if err := verifyManifestSignatureWithKey(pub, tampered, sig); err != ErrManifestSignatureInvalid {
+		t.Errorf("expected ErrManifestSignatureInvalid for tampered manifest, got %v", err)
+	}
+}
+
+func TestVerifyManifestSignatureNoKeyCompiledIn(t *testing.T) {
+	old := manifestPublicKeyHex
+	manifestPublicKeyHex = ""
+	defer func() { manifestPublicKeyHex = old }()
+
+	// With no release key compiled in, verification is skipped rather than
+	// rejecting every manifest against a placeholder key.
+	if err := verifyManifestSignature([]byte("anything"), []byte("anything")); err != nil {
+		t.Errorf("expected verification to be skipped, got %v", err)
+	}
+}
+
+func TestVerifyManifestSignatureWithCompiledInKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+
+	old := manifestPublicKeyHex
+	manifestPublicKeyHex = hex.EncodeToString(pub)
+	defer func() { manifestPublicKeyHex = old }()
+
+	manifest := []byte("plugins = []")
+	sig := ed25519.Sign(priv, manifest)
+
+	if err := verifyManifestSignature(manifest, sig); err != nil {
+		t.Errorf("expected valid signature to verify, got %v", err)
+	}
+
+	if err := verifyManifestSignature(manifest, []byte("not-a-signature")); err != ErrManifestSignatureInvalid {
+		t.Errorf("expected ErrManifestSignatureInvalid, got %v", err)
+	}
+}
+
+func TestManifestPublicKeyInvalidHex(t *testing.T) {
+	old := manifestPublicKeyHex
+	manifestPublicKeyHex = "not-hex!!"
+	defer func() { manifestPublicKeyHex = old }()
+
+	if _, err := manifestPublicKey(); err == nil {
+		t.Error("expected an error decoding invalid hex, got nil")
+	}
+}
+
+func TestManifestPublicKeyWrongLength(t *testing.T) {
+	old := manifestPublicKeyHex
+	manifestPublicKeyHex = hex.EncodeToString([]byte("too-short"))
+	defer func() { manifestPublicKeyHex = old }()
+
+	if _, err := manifestPublicKey(); err == nil {
+		t.Error("expected an error for a key of the wrong length, got nil")
+	}
+}
+
+func TestHashingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	hw := newHashingWriter(&buf)
+
+	data := []byte("plugin binary contents")
+	if _, err := hw.Write(data); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+
+	want := sha256.Sum256(data)
+	if got := hw.Sum(); got != hex.EncodeToString(want[:]) {
+		t.Errorf("Sum() = %s, want %s", got, hex.EncodeToString(want[:]))
+	}
+
+	if buf.String() != string(data) {
+		t.Errorf("underlying writer got %q, want %q", buf.String(), string(data))
+	}
+}
+
+func TestReleaseForPlatform(t *testing.T) {
+	plugin := Plugin{
+		Shortname: "foo",
+		Releases: []Release{
+			{OS: "bogus-os", Arch: "bogus-arch", Sum: "wrong"},
+			{OS: runtime.GOOS, Arch: runtime.GOARCH, Sum: "right"},
+		},
+	}
+
+	release, err := releaseForPlatform(plugin)
+	if err != nil {
+		t.Fatalf("releaseForPlatform: %v", err)
+	}
+
+	if release.Sum != "right" {
+		t.Errorf("releaseForPlatform returned %+v, want the matching release", release)
+	}
+
+	if _, err := releaseForPlatform(Plugin{Shortname: "foo"}); err == nil {
+		t.Error("expected an error when no release matches the current platform, got nil")
+	}
+}
+
+func TestIsSafePluginPathComponent(t *testing.T) {
+	safe := []string{"foo", "acme/foo", "stripe-foo"}
+	for _, name := range safe {
+		if !isSafePluginPathComponent(name) {
+			t.Errorf("isSafePluginPathComponent(%q) = false, want true", name)
+		}
+	}
+
+	unsafe := []string{"", "..", "../etc/passwd", "foo/../../bar", "/etc/passwd", "foo/.."}
+	for _, name := range unsafe {
+		if isSafePluginPathComponent(name) {
+			t.Errorf("isSafePluginPathComponent(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestPluginBinaryPathRejectsTraversal(t *testing.T) {
+	plugin := Plugin{Shortname: "../../../../tmp/evil", Binary: "stripe-evil"}
+
+	if _, err := pluginBinaryPath(nil, plugin); err == nil {
+		t.Error("expected a path-traversing shortname to be rejected, got nil error")
+	}
+}
+
+func TestPluginBinaryPathPrefersDiscoveredBinaryPath(t *testing.T) {
+	plugin := Plugin{Shortname: "foo", Binary: "stripe-foo", BinaryPath: "/usr/local/lib/stripe/plugins/stripe-foo"}
+
+	path, err := pluginBinaryPath(nil, plugin)
+	if err != nil {
+		t.Fatalf("pluginBinaryPath: %v", err)
+	}
+
+	if path != plugin.BinaryPath {
+		t.Errorf("pluginBinaryPath = %q, want the discovered BinaryPath %q", path, plugin.BinaryPath)
+	}
+}
+
+func TestDownloadPluginBinary(t *testing.T) {
+	body := []byte("totally real plugin binary")
+	sum := sha256.Sum256(body)
+	digest := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+
+	t.Run("matching digest", func(t *testing.T) {
+		dst, err := fs.Create("/plugins/foo/stripe-foo")
+		if err != nil {
+			t.Fatalf("creating destination: %v", err)
+		}
+
+		err = downloadPluginBinary(fs, dst, "/plugins/foo/stripe-foo", server.URL, Release{Sum: digest})
+		dst.Close()
+
+		if err != nil {
+			t.Fatalf("downloadPluginBinary: %v", err)
+		}
+
+		gotDigest, err := afero.ReadFile(fs, digestPath("/plugins/foo/stripe-foo"))
+		if err != nil {
+			t.Fatalf("reading stored digest: %v", err)
+		}
+
+		if string(gotDigest) != digest {
+			t.Errorf("stored digest = %s, want %s", gotDigest, digest)
+		}
+	})
+
+	t.Run("mismatched digest", func(t *testing.T) {
+		dst, err := fs.Create("/plugins/bar/stripe-bar")
+		if err != nil {
+			t.Fatalf("creating destination: %v", err)
+		}
+
+		err = downloadPluginBinary(fs, dst, "/plugins/bar/stripe-bar", server.URL, Release{Sum: "deadbeef"})
+		dst.Close()
+
+		if err == nil {
+			t.Fatal("expected a digest mismatch error, got nil")
+		}
+
+		if _, err := afero.ReadFile(fs, digestPath("/plugins/bar/stripe-bar")); err == nil {
+			t.Error("expected no digest to be stored for a mismatched download")
+		}
+	})
+}